@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// subcommands maps each ugf subcommand to its handler. Any invocation
+// whose first argument isn't one of these names falls back to legacyMain,
+// so existing `ugf -save`/`ugf -list`/`ugf -dump` scripts keep working.
+//
+// These names are reserved: `ugf <name> [path]` only resolves <name> as a
+// pattern when it isn't also a subcommand name. A pattern saved under one
+// of these words (e.g. "test", "run") is still reachable by forcing the
+// legacy positional form with `--`, e.g. `ugf -- test .` — "--" is never a
+// subcommand name, so it falls straight through to legacyMain(), and the
+// standard flag package's `--` handling there treats everything after it
+// literally.
+var subcommands = map[string]func([]string){
+	"save":   runSaveCmd,
+	"list":   runListCmd,
+	"run":    runRunCmd,
+	"dump":   runDumpCmd,
+	"test":   runTestCmd,
+	"edit":   runEditCmd,
+	"show":   runShowCmd,
+	"import": runImportCmd,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+	legacyMain()
+}
+
+func runSaveCmd(args []string) {
+	fs := flag.NewFlagSet("save", flag.ExitOnError)
+	flags := fs.String("flags", "", "engine flags to store with the pattern, e.g. --flags '-Hnri'")
+	single := fs.String("pattern", "", "single regex pattern")
+	multi := fs.StringArray("patterns", nil, "a regex alternative; repeat for multiple")
+	engine := fs.String("engine", "", "engine to run this pattern with (default ugrep)")
+	format := fs.String("format", "json", "pattern file format: json or yaml")
+	fromFile := fs.String("from-file", "", "load the pattern from an existing file instead of the flags above")
+	fs.Parse(args)
+
+	name := fs.Arg(0)
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "usage: ugf save [options] <name>")
+		os.Exit(1)
+	}
+
+	p := pattern{
+		Flags:    strings.Fields(*flags),
+		Pattern:  *single,
+		Patterns: *multi,
+		Engine:   *engine,
+	}
+
+	if *fromFile != "" {
+		loaded, err := decodePatternFile(*fromFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		p = loaded
+	}
+
+	if p.Pattern == "" && len(p.Patterns) == 0 {
+		fmt.Fprintln(os.Stderr, "pattern cannot be empty")
+		os.Exit(1)
+	}
+
+	if err := savePatternStruct(name, &p, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func runListCmd(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.Parse(args)
+
+	pats, err := getPatterns()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	if glob := fs.Arg(0); glob != "" {
+		filtered := pats[:0]
+		for _, p := range pats {
+			if ok, _ := path.Match(glob, p); ok {
+				filtered = append(filtered, p)
+			}
+		}
+		pats = filtered
+	}
+
+	fmt.Println(strings.Join(pats, "\n"))
+}
+
+func runRunCmd(args []string) {
+	execRun("run", args, false)
+}
+
+func runDumpCmd(args []string) {
+	execRun("dump", args, true)
+}
+
+// execRun implements both the `run` and `dump` subcommands; dump simply
+// forces --dry-run on.
+func execRun(cmdName string, args []string, forceDryRun bool) {
+	fs := flag.NewFlagSet(cmdName, flag.ExitOnError)
+	engine := fs.String("engine", "", "override the pattern's configured engine")
+	dryRun := fs.Bool("dry-run", false, "print the command that would run instead of executing it")
+	filesFrom := fs.String("files-from", "", "read files/dirs to search from this file, one per line")
+	jsonOut := fs.Bool("json", false, "emit normalized {file,line,col,match,pattern_name} JSON instead of the engine's raw output")
+	fs.Parse(args)
+
+	patName := fs.Arg(0)
+	files := fs.Arg(1)
+	if files == "" {
+		files = "."
+	}
+
+	pat, err := resolveSinglePattern(patName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *engine != "" {
+		pat.Engine = *engine
+	}
+
+	fileArgs := []string{files}
+	if *filesFrom != "" {
+		data, err := os.ReadFile(*filesFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		fileArgs = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line == "" {
+				continue
+			}
+			fileArgs = append(fileArgs, line)
+		}
+	}
+
+	if forceDryRun || *dryRun {
+		dumpInvocation(pat, fileArgs...)
+		return
+	}
+
+	var stdin io.Reader
+	if stdinIsPipe() {
+		stdin = os.Stdin
+	}
+
+	if *jsonOut {
+		writeMatchesJSON(pat, patName, stdin, fileArgs)
+		return
+	}
+
+	out, _ := runPattern(pat, stdin, fileArgs...)
+	os.Stdout.Write(out)
+}
+
+func runTestCmd(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	fs.Parse(args)
+
+	ok, err := runFixtures(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func runEditCmd(args []string) {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	fs.Parse(args)
+
+	name := fs.Arg(0)
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "usage: ugf edit <name>")
+		os.Exit(1)
+	}
+
+	patDir, err := getPatternDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to open user's pattern directory")
+		os.Exit(1)
+	}
+
+	filename, err := findPatternFile(patDir, name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	cmd := exec.Command(editor, filename)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Run()
+}
+
+func runShowCmd(args []string) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	fs.Parse(args)
+
+	name := fs.Arg(0)
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "usage: ugf show <name>")
+		os.Exit(1)
+	}
+
+	pat, err := loadPattern(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	operator := pat.Engine
+	if operator == "" {
+		operator = "ugrep"
+	}
+
+	fmt.Printf("name:    %s\n", name)
+	fmt.Printf("engine:  %s\n", operator)
+	fmt.Printf("flags:   %s\n", strings.Join(pat.Flags, " "))
+	fmt.Printf("pattern: %s\n", pat.Pattern)
+}
+
+func runImportCmd(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	name := fs.String("name", "", "name to import the pattern as (default: the source file's basename)")
+	format := fs.String("format", "", "force the stored format (json or yaml); default keeps the source's")
+	fs.Parse(args)
+
+	src := fs.Arg(0)
+	if src == "" {
+		fmt.Fprintln(os.Stderr, "usage: ugf import [options] <path>")
+		os.Exit(1)
+	}
+
+	pat, err := decodePatternFile(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if err := expandPatterns(&pat); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", src, err)
+		os.Exit(1)
+	}
+
+	destName := *name
+	if destName == "" {
+		destName = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+
+	destFormat := *format
+	if destFormat == "" {
+		switch filepath.Ext(src) {
+		case ".yaml", ".yml":
+			destFormat = "yaml"
+		default:
+			destFormat = "json"
+		}
+	}
+
+	if err := savePatternStruct(destName, &pat, destFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
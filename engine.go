@@ -0,0 +1,344 @@
+// Package main includes the engine adapters directly rather than under a
+// separate engine/ package: they're only ever consumed from within ugf
+// itself, and splitting them out would just add an import path for no
+// present benefit. Revisit if a second consumer shows up.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Match is the normalized shape of a single engine hit, used by -json
+// output so downstream tooling doesn't need to understand each engine's
+// native output format.
+type Match struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Col         int    `json:"col,omitempty"`
+	Text        string `json:"match"`
+	PatternName string `json:"pattern_name"`
+}
+
+// Engine abstracts over the search tool ugf shells out to, so a pattern's
+// Engine field ("grep", "ugrep", "rg"/"ripgrep", "git grep") transparently
+// produces the right invocation and can parse that tool's own output into
+// Matches.
+type Engine interface {
+	Name() string
+	BuildArgs(pat pattern, files []string) []string
+	ParseOutput(r io.Reader) ([]Match, error)
+}
+
+// engines maps a pattern's Engine field to its adapter. An empty Engine
+// field defaults to ugrep, matching ugf's historical behavior.
+var engines = map[string]Engine{
+	"":         ugrepEngine{},
+	"ugrep":    ugrepEngine{},
+	"grep":     grepEngine{},
+	"rg":       rgEngine{},
+	"ripgrep":  rgEngine{},
+	"git grep": gitGrepEngine{},
+}
+
+// engineFor resolves a pattern's Engine field to its adapter.
+func engineFor(name string) (Engine, error) {
+	e, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown engine %q", name)
+	}
+	return e, nil
+}
+
+// baseArgs builds the flags, regex argument(s), and files shared by every
+// adapter: one -e per alternative when Patterns is set, otherwise the
+// single Pattern.
+func baseArgs(pat pattern, files []string) []string {
+	args := []string{}
+	if len(pat.Patterns) > 0 {
+		args = append(args, pat.Flags...)
+		for _, p := range pat.Patterns {
+			args = append(args, "-e", p)
+		}
+	} else {
+		args = append(args, pat.Flags...)
+		args = append(args, pat.Pattern)
+	}
+	args = append(args, files...)
+	return args
+}
+
+func hasFlag(flags []string, want string) bool {
+	for _, f := range flags {
+		if f == want {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyFlag(flags []string, want ...string) bool {
+	for _, w := range want {
+		if hasFlag(flags, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// regexDialectFlags are the long/short spellings an engine accepts for
+// picking its regex dialect (basic vs extended vs PCRE vs fixed-string).
+// extendedRegexDefault checks this so it doesn't add its -E default on
+// top of a pattern that already chose a dialect.
+var regexDialectFlags = []string{
+	"-G", "--basic-regexp",
+	"-E", "--extended-regexp",
+	"-P", "--perl-regexp", "--pcre2",
+	"-F", "--fixed-strings",
+}
+
+// extendedRegexDefault returns {"-E"} unless flags already picked a
+// dialect, for engines (grep, git grep) that default to POSIX basic
+// regex. Shared by grepEngine/gitGrepEngine.BuildArgs and validate.go's
+// compileCheck so -validate checks regexes under the same dialect -run
+// actually invokes them with.
+func extendedRegexDefault(flags []string) []string {
+	if hasAnyFlag(flags, regexDialectFlags...) {
+		return nil
+	}
+	return []string{"-E"}
+}
+
+type ugrepEngine struct{}
+
+// BuildArgs passes flags through unchanged: ugrep's default dialect is
+// already extended-regex-like (alternation/+/?/{} need no flag), which
+// is what ugf patterns are authored against, so there's no translation
+// to do here.
+func (ugrepEngine) Name() string                                   { return "ugrep" }
+func (ugrepEngine) BuildArgs(pat pattern, files []string) []string { return baseArgs(pat, files) }
+func (ugrepEngine) ParseOutput(r io.Reader) ([]Match, error)       { return parseUgrepJSON(r) }
+
+type grepEngine struct{}
+
+func (grepEngine) Name() string { return "grep" }
+
+// BuildArgs adds -r when the pattern didn't already request recursion:
+// unlike ugrep and rg, plain grep doesn't recurse into directories by
+// default. -r is only meaningful with a path operand: with none (stdin is
+// being piped, files is empty) grep reads stdin, and adding -r would make
+// it search the cwd instead.
+//
+// It also defaults to -E: ugf patterns are authored against ugrep/rg,
+// which both treat alternation/+/?/{} as extended-regex syntax with no
+// flag needed, whereas plain grep defaults to POSIX basic regex and
+// would otherwise treat "(foo|bar)" as a literal string. Skipped when
+// the pattern already picked a dialect (-G/-E/-P/-F).
+func (grepEngine) BuildArgs(pat pattern, files []string) []string {
+	args := baseArgs(pat, files)
+	if len(files) > 0 && !hasAnyFlag(pat.Flags, "-r", "-R", "--recursive") {
+		args = append([]string{"-r"}, args...)
+	}
+	return append(extendedRegexDefault(pat.Flags), args...)
+}
+
+func (grepEngine) ParseOutput(r io.Reader) ([]Match, error) { return parseGrepLikeOutput(r, "grep") }
+
+type rgEngine struct{}
+
+// BuildArgs passes flags through unchanged: like ugrep, rg's default
+// regex syntax is already extended-like, so no basic/extended toggle is
+// needed.
+func (rgEngine) Name() string                                   { return "rg" }
+func (rgEngine) BuildArgs(pat pattern, files []string) []string { return baseArgs(pat, files) }
+func (rgEngine) ParseOutput(r io.Reader) ([]Match, error)       { return parseRgJSON(r) }
+
+type gitGrepEngine struct{}
+
+func (gitGrepEngine) Name() string { return "git" }
+
+// BuildArgs prepends the "grep" subcommand, since the binary to exec is
+// "git", not "git grep". Like plain grep, "git grep" defaults to POSIX
+// basic regex, so it gets the same -E default as grepEngine unless the
+// pattern already picked a dialect.
+func (gitGrepEngine) BuildArgs(pat pattern, files []string) []string {
+	args := append(extendedRegexDefault(pat.Flags), baseArgs(pat, files)...)
+	return append([]string{"grep"}, args...)
+}
+
+func (gitGrepEngine) ParseOutput(r io.Reader) ([]Match, error) {
+	return parseGrepLikeOutput(r, "git grep")
+}
+
+// jsonFlags lists the extra argument that turns on an engine's native JSON
+// output, for engines that have one. Engines absent from this map fall
+// back to parsing their plain "-n" text output.
+var jsonFlags = map[string][]string{
+	"ugrep": {"--json"},
+	"rg":    {"--json"},
+}
+
+// insertLineNumberFlag adds -n so parseGrepLikeOutput has a line number to
+// key off of, for engines with no native JSON output. git grep needs -n
+// placed after its leading "grep" subcommand: the binary exec'd is "git",
+// and "git -n grep ..." rejects -n as an option of git itself rather than
+// of the grep subcommand.
+func insertLineNumberFlag(engineName string, args []string) []string {
+	if hasFlag(args, "-n") {
+		return args
+	}
+	if engineName == "git" && len(args) > 0 && args[0] == "grep" {
+		return append([]string{"grep", "-n"}, args[1:]...)
+	}
+	return append([]string{"-n"}, args...)
+}
+
+// runPatternStructured runs pat's engine and returns normalized Matches,
+// tagging each with name so -json output can be traced back to the
+// pattern that produced it.
+func runPatternStructured(pat pattern, name string, stdin io.Reader, files []string) ([]Match, error) {
+	e, err := engineFor(pat.Engine)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileArgs []string
+	if stdin == nil {
+		fileArgs = files
+	}
+	args := e.BuildArgs(pat, fileArgs)
+	if extra, ok := jsonFlags[e.Name()]; ok {
+		args = append(extra, args...)
+	} else {
+		// no native JSON output: make sure line numbers are present so
+		// parseGrepLikeOutput has something to key off of.
+		args = insertLineNumberFlag(e.Name(), args)
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command(e.Name(), args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if runErr != nil {
+		// exit 1 means "no matches", which every grep-like tool here uses
+		// to mean success-with-nothing-found, not a real failure.
+		if !errors.As(runErr, &exitErr) || exitErr.ExitCode() != 1 {
+			return nil, fmt.Errorf("%s failed: %s", e.Name(), strings.TrimSpace(stderr.String()))
+		}
+	}
+
+	matches, err := e.ParseOutput(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if matches == nil {
+		// Keep the -json contract an array even with zero hits, rather
+		// than letting a nil slice encode as "null".
+		matches = []Match{}
+	}
+	for i := range matches {
+		matches[i].PatternName = name
+	}
+	return matches, nil
+}
+
+// parseUgrepJSON parses ugrep's --json output: a single JSON array of
+// per-file match groups.
+func parseUgrepJSON(r io.Reader) ([]Match, error) {
+	var raw []struct {
+		File    string `json:"file"`
+		Matches []struct {
+			Line   int    `json:"line"`
+			Column int    `json:"column"`
+			Match  string `json:"match"`
+		} `json:"matches"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse ugrep --json output: %s", err)
+	}
+
+	var matches []Match
+	for _, f := range raw {
+		for _, m := range f.Matches {
+			matches = append(matches, Match{File: f.File, Line: m.Line, Col: m.Column, Text: m.Match})
+		}
+	}
+	return matches, nil
+}
+
+// parseRgJSON parses ripgrep's --json output: newline-delimited JSON
+// objects, one of which is a "match" event per hit.
+func parseRgJSON(r io.Reader) ([]Match, error) {
+	var matches []Match
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Type string `json:"type"`
+			Data struct {
+				Path struct {
+					Text string `json:"text"`
+				} `json:"path"`
+				LineNumber int `json:"line_number"`
+				Submatches []struct {
+					Match struct {
+						Text string `json:"text"`
+					} `json:"match"`
+					Start int `json:"start"`
+				} `json:"submatches"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Type != "match" {
+			continue
+		}
+		for _, sm := range event.Data.Submatches {
+			matches = append(matches, Match{
+				File: event.Data.Path.Text,
+				Line: event.Data.LineNumber,
+				Col:  sm.Start + 1,
+				Text: sm.Match.Text,
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse rg --json output: %s", err)
+	}
+	return matches, nil
+}
+
+// grepLineRE matches the traditional "file:line:text" shape that grep and
+// git grep share when run with -n.
+var grepLineRE = regexp.MustCompile(`^([^:]+):(\d+):(.*)$`)
+
+func parseGrepLikeOutput(r io.Reader, engineName string) ([]Match, error) {
+	var matches []Match
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := grepLineRE.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		line, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		matches = append(matches, Match{File: m[1], Line: line, Text: strings.TrimSpace(m[3])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s output: %s", engineName, err)
+	}
+	return matches, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGrepEngineBuildArgsOmitsRecursiveWhenPipingStdin(t *testing.T) {
+	cases := []struct {
+		name  string
+		files []string
+		flags []string
+		wantR bool
+	}{
+		{"no files (stdin piped)", nil, nil, false},
+		{"with files", []string{"."}, nil, true},
+		{"already requests -r", []string{"."}, []string{"-r"}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pat := pattern{Pattern: "foo", Flags: c.flags}
+			args := grepEngine{}.BuildArgs(pat, c.files)
+
+			count := 0
+			for _, a := range args {
+				if a == "-r" {
+					count++
+				}
+			}
+			if got := count > 0; got != c.wantR {
+				t.Errorf("-r present = %v, want %v (args=%v)", got, c.wantR, args)
+			}
+			if count > 1 {
+				t.Errorf("-r duplicated: %v", args)
+			}
+		})
+	}
+}
+
+func TestGrepEngineBuildArgsDefaultsToExtendedRegex(t *testing.T) {
+	cases := []struct {
+		name        string
+		flags       []string
+		addsDialect bool // whether BuildArgs should add its own -E on top of flags
+	}{
+		{"no dialect flag", nil, true},
+		{"already basic", []string{"-G"}, false},
+		{"already extended", []string{"-E"}, false},
+		{"already perl", []string{"--perl-regexp"}, false},
+		{"already fixed", []string{"-F"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pat := pattern{Pattern: "(foo|bar)", Flags: c.flags}
+			args := grepEngine{}.BuildArgs(pat, nil)
+
+			wantLen := len(c.flags) + 1 // + the pattern itself
+			if c.addsDialect {
+				wantLen++
+			}
+			if len(args) != wantLen {
+				t.Errorf("got args %v (len %d), want len %d", args, len(args), wantLen)
+			}
+			if c.addsDialect && args[0] != "-E" {
+				t.Errorf("expected -E prepended, got %v", args)
+			}
+		})
+	}
+}
+
+func TestGitGrepEngineBuildArgsDefaultsToExtendedRegex(t *testing.T) {
+	pat := pattern{Pattern: "(foo|bar)"}
+	args := gitGrepEngine{}.BuildArgs(pat, nil)
+
+	if args[0] != "grep" {
+		t.Fatalf("expected args to start with the grep subcommand, got %v", args)
+	}
+	if !hasFlag(args[1:], "-E") {
+		t.Errorf("expected -E to be added by default, got %v", args)
+	}
+
+	pat2 := pattern{Pattern: "(foo|bar)", Flags: []string{"-G"}}
+	args2 := gitGrepEngine{}.BuildArgs(pat2, nil)
+	if hasFlag(args2, "-E") {
+		t.Errorf("-E should not be added when a dialect flag is already set: %v", args2)
+	}
+}
+
+func TestBaseArgsMultiplePatterns(t *testing.T) {
+	pat := pattern{Patterns: []string{"a", "b"}, Flags: []string{"-i"}}
+	args := baseArgs(pat, []string{"."})
+
+	want := []string{"-i", "-e", "a", "-e", "b", "."}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", args, want)
+	}
+}
+
+func TestParseGrepLikeOutput(t *testing.T) {
+	in := "main.go:12:some matched text\nother.go:3:more\n"
+	matches, err := parseGrepLikeOutput(strings.NewReader(in), "grep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2", len(matches))
+	}
+	if matches[0].File != "main.go" || matches[0].Line != 12 || matches[0].Text != "some matched text" {
+		t.Errorf("unexpected first match: %+v", matches[0])
+	}
+}
+
+func TestParseUgrepJSON(t *testing.T) {
+	in := `[{"file":"a.go","matches":[{"line":1,"column":2,"match":"foo"}]}]`
+	matches, err := parseUgrepJSON(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].File != "a.go" || matches[0].Line != 1 || matches[0].Col != 2 || matches[0].Text != "foo" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+// nilMatchEngine is a stub Engine whose ParseOutput always returns a nil
+// slice, mimicking what grep-like parsers return on zero hits. It execs
+// the real "true" binary (always exits 0 with no output) so exercising
+// it through runPatternStructured doesn't depend on a fake binary name.
+type nilMatchEngine struct{}
+
+func (nilMatchEngine) Name() string                                   { return "true" }
+func (nilMatchEngine) BuildArgs(pat pattern, files []string) []string { return nil }
+func (nilMatchEngine) ParseOutput(r io.Reader) ([]Match, error)       { return nil, nil }
+
+func TestRunPatternStructuredNormalizesNilMatchesToEmptySlice(t *testing.T) {
+	engines["nilmatch"] = nilMatchEngine{}
+	defer delete(engines, "nilmatch")
+
+	matches, err := runPatternStructured(pattern{Engine: "nilmatch", Pattern: "foo"}, "foo", strings.NewReader(""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matches == nil {
+		t.Fatal("matches is nil, want non-nil empty slice so -json encodes [] not null")
+	}
+	if len(matches) != 0 {
+		t.Errorf("got %d matches, want 0", len(matches))
+	}
+}
+
+func TestInsertLineNumberFlag(t *testing.T) {
+	cases := []struct {
+		name       string
+		engineName string
+		args       []string
+		want       []string
+	}{
+		{"grep prepends -n", "grep", []string{"-E", "foo"}, []string{"-n", "-E", "foo"}},
+		{"git grep inserts -n after the grep subcommand", "git", []string{"grep", "-E", "foo"}, []string{"grep", "-n", "-E", "foo"}},
+		{"already has -n", "grep", []string{"-n", "foo"}, []string{"-n", "foo"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := insertLineNumberFlag(c.engineName, c.args)
+			if strings.Join(got, " ") != strings.Join(c.want, " ") {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestRunPatternStructuredGitGrepJSON is a regression test for a real exec
+// path: git grep's -n must land after the "grep" subcommand, or "git -n
+// grep ..." fails and runPatternStructured used to silently report zero
+// matches instead of the underlying exec error.
+func TestRunPatternStructuredGitGrepJSON(t *testing.T) {
+	dir := t.TempDir()
+	run := func(name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s %v: %s: %s", name, args, err, out)
+		}
+	}
+	run("git", "init", "-q")
+	run("git", "config", "user.email", "test@example.com")
+	run("git", "config", "user.name", "test")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("foo here\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("git", "add", "a.txt")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	matches, err := runPatternStructured(pattern{Engine: "git grep", Pattern: "foo"}, "foo", nil, []string{"."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].File != "a.txt" || matches[0].Line != 1 {
+		t.Errorf("got %+v, want one match in a.txt line 1", matches)
+	}
+}
+
+func TestParseRgJSON(t *testing.T) {
+	in := `{"type":"begin","data":{}}
+{"type":"match","data":{"path":{"text":"a.go"},"line_number":5,"submatches":[{"match":{"text":"foo"},"start":3}]}}
+{"type":"end","data":{}}
+`
+	matches, err := parseRgJSON(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].File != "a.go" || matches[0].Line != 5 || matches[0].Col != 4 || matches[0].Text != "foo" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// resolvePatterns returns every pattern whose name matches glob
+// (path.Match semantics against the pattern's stem, e.g. "xss-*"),
+// decoded straight from disk without the single-Pattern expansion that
+// loadPattern applies.
+func resolvePatterns(glob string) ([]pattern, error) {
+	patDir, err := getPatternDir()
+	if err != nil {
+		return nil, errors.New("unable to open user's pattern directory")
+	}
+
+	names, err := getPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, n := range names {
+		ok, err := path.Match(glob, n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob '%s': %s", glob, err)
+		}
+		if ok {
+			matched = append(matched, n)
+		}
+	}
+	sort.Strings(matched)
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no patterns match '%s'", glob)
+	}
+
+	pats := make([]pattern, 0, len(matched))
+	for _, n := range matched {
+		filename, err := findPatternFile(patDir, n)
+		if err != nil {
+			return nil, err
+		}
+		pat, err := decodePatternFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		pats = append(pats, pat)
+	}
+
+	return pats, nil
+}
+
+// mergePatterns unions the Flags and regexes of multiple patterns into a
+// single pattern suitable for one combined engine invocation. Engine
+// values must agree; an empty Engine defers to the others.
+func mergePatterns(pats []pattern) (pattern, error) {
+	merged := pattern{}
+	seenFlag := map[string]bool{}
+	seenRegex := map[string]bool{}
+
+	for _, pat := range pats {
+		if pat.Engine != "" {
+			if merged.Engine != "" && merged.Engine != pat.Engine {
+				return pattern{}, fmt.Errorf("conflicting engines: '%s' vs '%s'", merged.Engine, pat.Engine)
+			}
+			merged.Engine = pat.Engine
+		}
+
+		for _, f := range pat.Flags {
+			if !seenFlag[f] {
+				seenFlag[f] = true
+				merged.Flags = append(merged.Flags, f)
+			}
+		}
+
+		regexes := pat.Patterns
+		if pat.Pattern != "" {
+			regexes = append(regexes, pat.Pattern)
+		}
+		for _, r := range regexes {
+			if !seenRegex[r] {
+				seenRegex[r] = true
+				merged.Patterns = append(merged.Patterns, r)
+			}
+		}
+	}
+
+	if err := expandPatterns(&merged); err != nil {
+		return pattern{}, err
+	}
+
+	return merged, nil
+}
+
+// resolveSinglePattern loads a single named pattern, or, when name
+// contains glob metacharacters, resolves and merges every matching
+// pattern into one.
+func resolveSinglePattern(name string) (pattern, error) {
+	if hasGlobMeta(name) {
+		pats, err := resolvePatterns(name)
+		if err != nil {
+			return pattern{}, err
+		}
+		return mergePatterns(pats)
+	}
+	return loadPattern(name)
+}
+
+func hasGlobMeta(name string) bool {
+	for _, r := range name {
+		switch r {
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
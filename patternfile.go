@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// patternExts lists the file extensions a pattern may be stored under, in
+// the order findPatternFile checks them.
+var patternExts = []string{".json", ".yaml", ".yml"}
+
+// knownPatternFields are the fields the pattern struct understands; used by
+// -validate to warn about typos or stale keys.
+var knownPatternFields = map[string]bool{
+	"flags": true, "pattern": true, "patterns": true, "engine": true,
+}
+
+// findPatternFile locates name's pattern file in patDir across the
+// supported formats, rejecting the same name defined more than once.
+func findPatternFile(patDir, name string) (string, error) {
+	var found []string
+	for _, ext := range patternExts {
+		p := filepath.Join(patDir, name+ext)
+		if _, err := os.Stat(p); err == nil {
+			found = append(found, p)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", errors.New("no such pattern")
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("duplicate pattern '%s' defined in multiple files: %s", name, strings.Join(found, ", "))
+	}
+}
+
+// decodePatternFile reads and decodes a pattern file, picking JSON or YAML
+// based on its extension.
+func decodePatternFile(path string) (pattern, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return pattern{}, errors.New("no such pattern")
+	}
+	defer f.Close()
+
+	pat := pattern{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(f).Decode(&pat); err != nil {
+			return pattern{}, fmt.Errorf("pattern file '%s' is malformed: %s", path, err)
+		}
+	default:
+		if err := json.NewDecoder(f).Decode(&pat); err != nil {
+			return pattern{}, fmt.Errorf("pattern file '%s' is malformed: %s", path, err)
+		}
+	}
+	return pat, nil
+}
+
+// expandPatterns collapses a Patterns list into a single Pattern
+// alternation when Pattern is empty. The engine adapters pass Patterns as
+// one -e per alternative whenever it is set (see baseArgs), so none of
+// them reads the collapsed Pattern.
+func expandPatterns(pat *pattern) error {
+	if pat.Pattern == "" {
+		if len(pat.Patterns) == 0 {
+			return errors.New("contains no pattern(s)")
+		}
+		pat.Pattern = "(" + strings.Join(pat.Patterns, "|") + ")"
+	}
+	return nil
+}
+
+// unknownPatternFields re-reads path as a loosely-typed map and reports any
+// top-level keys the pattern struct doesn't recognize.
+func unknownPatternFields(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("no such pattern")
+	}
+	defer f.Close()
+
+	raw := map[string]interface{}{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(f).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("pattern file '%s' is malformed: %s", path, err)
+		}
+	default:
+		if err := json.NewDecoder(f).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("pattern file '%s' is malformed: %s", path, err)
+		}
+	}
+
+	var unknown []string
+	for k := range raw {
+		if !knownPatternFields[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	return unknown, nil
+}
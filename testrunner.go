@@ -0,0 +1,139 @@
+// Kept in package main alongside engine.go rather than split into its own
+// testrunner/ package; see the note atop engine.go.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fixtureSuffix marks a fixture file as distinct from a pattern file so
+// getPatterns/resolvePatterns never surface "xss-reflected.test" as a
+// pattern name just because "xss-reflected.test.yaml" matches *.yaml.
+const fixtureSuffix = ".test.yaml"
+
+// fixture is a regression test bundled alongside a pattern, e.g.
+// ~/.config/gf/xss.test.yaml. Lines are piped to the pattern's engine on
+// stdin; expected_matches and must_not_match are checked against the
+// captured stdout as regexes (a fragment is enough to match).
+type fixture struct {
+	Lines           []string `yaml:"lines"`
+	ExpectedMatches []string `yaml:"expected_matches,omitempty"`
+	MustNotMatch    []string `yaml:"must_not_match,omitempty"`
+}
+
+func loadFixture(path string) (*fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fx := &fixture{}
+	if err := yaml.Unmarshal(data, fx); err != nil {
+		return nil, fmt.Errorf("fixture '%s' is malformed: %s", path, err)
+	}
+	return fx, nil
+}
+
+// runFixtures runs the named pattern's fixture, or every pattern's fixture
+// when name is empty, reporting pass/fail counts and diffs to stdout. It
+// returns false if any fixture failed.
+func runFixtures(name string) (bool, error) {
+	patDir, err := getPatternDir()
+	if err != nil {
+		return false, fmt.Errorf("unable to open user's pattern directory: %s", err)
+	}
+
+	names := []string{name}
+	if name == "" {
+		fixtures, err := filepath.Glob(filepath.Join(patDir, "*"+fixtureSuffix))
+		if err != nil {
+			return false, err
+		}
+		names = names[:0]
+		for _, f := range fixtures {
+			names = append(names, strings.TrimSuffix(filepath.Base(f), fixtureSuffix))
+		}
+	}
+
+	if len(names) == 0 {
+		fmt.Println("no fixtures found")
+		return true, nil
+	}
+
+	allOK := true
+	for _, n := range names {
+		ok, err := runFixture(patDir, n)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", n, err)
+			allOK = false
+			continue
+		}
+		if !ok {
+			allOK = false
+		}
+	}
+
+	return allOK, nil
+}
+
+func runFixture(patDir, name string) (bool, error) {
+	fxPath := filepath.Join(patDir, name+fixtureSuffix)
+	fx, err := loadFixture(fxPath)
+	if err != nil {
+		return false, err
+	}
+
+	pat, err := loadPattern(name)
+	if err != nil {
+		return false, err
+	}
+
+	// runPattern omits file args whenever stdin is non-nil (see buildArgs),
+	// so engines that read stdin when given no path search fx.Lines rather
+	// than the pattern directory. git grep never reads stdin: with no path
+	// it searches the current repository instead.
+	input := strings.NewReader(strings.Join(fx.Lines, "\n") + "\n")
+	out, _ := runPattern(pat, input)
+	output := string(out)
+
+	passed := 0
+	failed := 0
+
+	for _, want := range fx.ExpectedMatches {
+		if matched(want, output) {
+			passed++
+		} else {
+			failed++
+			fmt.Printf("%s: FAIL expected match %q, got:\n%s\n", name, want, output)
+		}
+	}
+
+	for _, unwanted := range fx.MustNotMatch {
+		if matched(unwanted, output) {
+			failed++
+			fmt.Printf("%s: FAIL unexpected match %q, got:\n%s\n", name, unwanted, output)
+		} else {
+			passed++
+		}
+	}
+
+	fmt.Printf("%s: %d passed, %d failed\n", name, passed, failed)
+	return failed == 0, nil
+}
+
+// matched reports whether want appears in output, either as a regex or,
+// if it doesn't compile, as a literal substring.
+func matched(want, output string) bool {
+	re, err := regexp.Compile(want)
+	if err != nil {
+		return strings.Contains(output, want)
+	}
+	return re.MatchString(output)
+}
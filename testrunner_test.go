@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestMatchedRegexAndLiteral(t *testing.T) {
+	out := "alert(1)\nfoo bar\n"
+
+	if !matched(`alert\(1\)`, out) {
+		t.Error("expected regex to match")
+	}
+	if !matched("foo bar", out) {
+		t.Error("expected literal substring to match")
+	}
+	if matched("nope", out) {
+		t.Error("expected no match")
+	}
+	// "(" alone doesn't compile as a regex, so matched falls back to a
+	// literal substring check rather than erroring.
+	if !matched("(", "x(y") {
+		t.Error("expected fallback to literal match for invalid regex")
+	}
+}
+
+func TestLoadFixtureMissingFile(t *testing.T) {
+	if _, err := loadFixture("/nonexistent/path.test.yaml"); err == nil {
+		t.Error("expected an error for a missing fixture file")
+	}
+}
@@ -1,22 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/user"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 type pattern struct {
-	Flags    []string `json:"flags,omitempty"`
-	Pattern  string   `json:"pattern,omitempty"`
-	Patterns []string `json:"patterns,omitempty"`
-	Engine   string   `json:"engine,omitempty"`
+	Flags    []string `json:"flags,omitempty" yaml:"flags,omitempty"`
+	Pattern  string   `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Patterns []string `json:"patterns,omitempty" yaml:"patterns,omitempty"`
+	Engine   string   `json:"engine,omitempty" yaml:"engine,omitempty"`
 }
 
 // Allow backward-compatible unmarshalling for flags as string or []string
@@ -48,7 +54,42 @@ func (p *pattern) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func main() {
+// UnmarshalYAML mirrors UnmarshalJSON: flags may be written as a single
+// space-separated string or as a YAML sequence.
+func (p *pattern) UnmarshalYAML(value *yaml.Node) error {
+	aux := &struct {
+		Flags    yaml.Node `yaml:"flags"`
+		Pattern  string    `yaml:"pattern"`
+		Patterns []string  `yaml:"patterns"`
+		Engine   string    `yaml:"engine"`
+	}{}
+	if err := value.Decode(aux); err != nil {
+		return err
+	}
+	p.Pattern, p.Patterns, p.Engine = aux.Pattern, aux.Patterns, aux.Engine
+	switch aux.Flags.Kind {
+	case yaml.ScalarNode:
+		var s string
+		if err := aux.Flags.Decode(&s); err != nil {
+			return err
+		}
+		if s != "" {
+			p.Flags = strings.Fields(s)
+		}
+	case yaml.SequenceNode:
+		var flags []string
+		if err := aux.Flags.Decode(&flags); err != nil {
+			return err
+		}
+		p.Flags = flags
+	}
+	return nil
+}
+
+// legacyMain implements the original flat -save/-list/-dump/-test/-validate
+// flag.BoolVar-style CLI, kept as a compatibility shim for scripts that
+// invoke ugf without one of the subcommands in cli.go.
+func legacyMain() {
 	var saveMode bool
 	flag.BoolVar(&saveMode, "save", false, "save a pattern (e.g: ugf -save pat-name -Hnri 'search-pattern')")
 
@@ -58,14 +99,48 @@ func main() {
 	var dumpMode bool
 	flag.BoolVar(&dumpMode, "dump", false, "prints the grep command rather than executing it")
 
+	var testMode bool
+	flag.BoolVar(&testMode, "test", false, "run a pattern's fixture(s) and report pass/fail (e.g: ugf -test xss, or ugf -test for all patterns)")
+
+	var format string
+	flag.StringVar(&format, "format", "json", "pattern file format to use with -save (json or yaml)")
+
+	var validateName string
+	flag.StringVar(&validateName, "validate", "", "validate a pattern file's syntax and regex compilation (e.g: ugf -validate xss)")
+
+	var jsonMode bool
+	flag.BoolVar(&jsonMode, "json", false, "emit normalized {file,line,col,match,pattern_name} JSON instead of the engine's raw output")
+
 	flag.Parse()
 
+	if validateName != "" {
+		warnings, err := validatePattern(validateName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		for _, w := range warnings {
+			fmt.Printf("warning: %s\n", w)
+		}
+		fmt.Printf("%s: ok\n", validateName)
+		return
+	}
+
 	if listMode {
 		pats, err := getPatterns()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err)
 			return
 		}
+		if glob := flag.Arg(0); glob != "" {
+			filtered := pats[:0]
+			for _, p := range pats {
+				if ok, _ := path.Match(glob, p); ok {
+					filtered = append(filtered, p)
+				}
+			}
+			pats = filtered
+		}
 		fmt.Println(strings.Join(pats, "\n"))
 		return
 	}
@@ -75,94 +150,142 @@ func main() {
 		flags := flag.Arg(1)
 		pattern := flag.Arg(2)
 
-		err := savePattern(name, flags, pattern)
+		if format != "json" && format != "yaml" {
+			fmt.Fprintf(os.Stderr, "unknown -format %q, expected json or yaml\n", format)
+			return
+		}
+
+		err := savePattern(name, flags, pattern, format)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err)
 		}
 		return
 	}
 
+	if testMode {
+		ok, err := runFixtures(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
 	patName := flag.Arg(0)
 	files := flag.Arg(1)
 	if files == "" {
 		files = "."
 	}
 
-	patDir, err := getPatternDir()
+	pat, err := resolveSinglePattern(patName)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "unable to open user's pattern directory")
+		fmt.Fprintln(os.Stderr, err)
 		return
 	}
 
-	filename := filepath.Join(patDir, patName+".json")
-	f, err := os.Open(filename)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "no such pattern")
+	if dumpMode {
+		dumpInvocation(pat, files)
 		return
 	}
-	defer f.Close()
 
-	pat := pattern{}
-	dec := json.NewDecoder(f)
-	err = dec.Decode(&pat)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "pattern file '%s' is malformed: %s\n", filename, err)
+	var stdin io.Reader
+	if stdinIsPipe() {
+		stdin = os.Stdin
+	}
+
+	if jsonMode {
+		writeMatchesJSON(pat, patName, stdin, []string{files})
 		return
 	}
 
-	if pat.Pattern == "" {
-		// check for multiple patterns
-		if len(pat.Patterns) == 0 {
-			fmt.Fprintf(os.Stderr, "pattern file '%s' contains no pattern(s)\n", filename)
-			return
-		}
-		pat.Pattern = "(" + strings.Join(pat.Patterns, "|") + ")"
+	out, _ := runPattern(pat, stdin, files)
+	os.Stdout.Write(out)
+}
+
+// writeMatchesJSON runs pat's engine, normalizes the result, and encodes
+// it as a JSON array on stdout.
+func writeMatchesJSON(pat pattern, name string, stdin io.Reader, files []string) {
+	matches, err := runPatternStructured(pat, name, stdin, files)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	if err := enc.Encode(matches); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
 	}
+}
 
-	operator := pat.Engine
-	if operator == "" {
-		operator = "ugrep"
+// loadPattern reads and validates the named pattern file from the user's
+// pattern directory, expanding a Patterns list into a single alternation.
+// It accepts both JSON and YAML pattern files.
+func loadPattern(name string) (pattern, error) {
+	patDir, err := getPatternDir()
+	if err != nil {
+		return pattern{}, errors.New("unable to open user's pattern directory")
 	}
 
-	if dumpMode {
-		if operator == "ugrep" && len(pat.Patterns) > 0 {
-			args := []string{}
-			args = append(args, pat.Flags...)
-			for _, p := range pat.Patterns {
-				args = append(args, "-e", p)
-			}
-			if files != "" {
-				args = append(args, files)
-			}
-			fmt.Printf("%s %s\n", operator, strings.Join(args, " "))
-		} else {
-			fmt.Printf("%s %s %q %v\n",
-				operator, strings.Join(pat.Flags, " "), pat.Pattern, files)
-		}
-	} else {
-		var cmd *exec.Cmd
-		args := []string{}
-		if operator == "ugrep" && len(pat.Patterns) > 0 {
-			args = append(args, pat.Flags...)
-			for _, p := range pat.Patterns {
-				args = append(args, "-e", p)
-			}
-			if !stdinIsPipe() && files != "" {
-				args = append(args, files)
-			}
-		} else {
-			args = append(args, pat.Flags...)
-			args = append(args, pat.Pattern)
-			if !stdinIsPipe() && files != "" {
-				args = append(args, files)
-			}
-		}
-		cmd = exec.Command(operator, args...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Run()
+	filename, err := findPatternFile(patDir, name)
+	if err != nil {
+		return pattern{}, err
+	}
+
+	pat, err := decodePatternFile(filename)
+	if err != nil {
+		return pattern{}, err
+	}
+
+	if err := expandPatterns(&pat); err != nil {
+		return pattern{}, fmt.Errorf("pattern file '%s' %s", filename, err)
+	}
+
+	return pat, nil
+}
+
+// runPattern builds the engine invocation for pat and runs it, capturing
+// stdout. If stdin is non-nil it is piped to the engine and files is
+// ignored, mirroring how stdinIsPipe suppresses the file argument during
+// normal operation.
+func runPattern(pat pattern, stdin io.Reader, files ...string) ([]byte, error) {
+	operator, args := buildArgs(pat, stdin == nil, files)
+
+	cmd := exec.Command(operator, args...)
+	cmd.Stdin = stdin
+	cmd.Stderr = os.Stderr
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	return out.Bytes(), err
+}
+
+// buildArgs assembles the engine name and argument list for pat, via the
+// Engine adapter matching pat.Engine. When includeFiles is false (piping
+// via stdin) the files are omitted. An unrecognized Engine falls back to
+// ugrep, matching ugf's historical default.
+func buildArgs(pat pattern, includeFiles bool, files []string) (string, []string) {
+	e, err := engineFor(pat.Engine)
+	if err != nil {
+		e = ugrepEngine{}
+	}
+
+	var fileArgs []string
+	if includeFiles {
+		fileArgs = files
 	}
+
+	return e.Name(), e.BuildArgs(pat, fileArgs)
+}
+
+// dumpInvocation prints the engine command pat would run against files,
+// without executing it.
+func dumpInvocation(pat pattern, files ...string) {
+	operator, args := buildArgs(pat, true, files)
+	fmt.Printf("%s %s\n", operator, strings.Join(args, " "))
 }
 
 func getPatternDir() (string, error) {
@@ -178,16 +301,22 @@ func getPatternDir() (string, error) {
 	return filepath.Join(usr.HomeDir, ".gf"), nil
 }
 
-func savePattern(name, flags, pat string) error {
-	if name == "" {
-		return errors.New("name cannot be empty")
-	}
+func savePattern(name, flags, pat, format string) error {
 	if pat == "" {
 		return errors.New("pattern cannot be empty")
 	}
-	p := &pattern{
+	return savePatternStruct(name, &pattern{
 		Flags:   strings.Fields(flags),
 		Pattern: pat,
+	}, format)
+}
+
+// savePatternStruct writes an already-built pattern to the user's pattern
+// directory as either JSON or YAML, refusing to clobber an existing
+// pattern of the same name under any supported format.
+func savePatternStruct(name string, p *pattern, format string) error {
+	if name == "" {
+		return errors.New("name cannot be empty")
 	}
 
 	patDir, err := getPatternDir()
@@ -195,13 +324,31 @@ func savePattern(name, flags, pat string) error {
 		return fmt.Errorf("failed to determine pattern directory: %s", err)
 	}
 
-	path := filepath.Join(patDir, name+".json")
+	if _, err := findPatternFile(patDir, name); err == nil {
+		return fmt.Errorf("pattern '%s' already exists", name)
+	}
+
+	ext := ".json"
+	if format == "yaml" {
+		ext = ".yaml"
+	}
+
+	path := filepath.Join(patDir, name+ext)
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
 	if err != nil {
 		return fmt.Errorf("failed to create pattern file: %s", err)
 	}
 	defer f.Close()
 
+	if format == "yaml" {
+		enc := yaml.NewEncoder(f)
+		defer enc.Close()
+		if err := enc.Encode(p); err != nil {
+			return fmt.Errorf("failed to write pattern file: %s", err)
+		}
+		return nil
+	}
+
 	enc := json.NewEncoder(f)
 	enc.SetIndent("", "    ")
 
@@ -213,24 +360,36 @@ func savePattern(name, flags, pat string) error {
 	return nil
 }
 
+// getPatterns returns the de-duplicated union of every pattern name found
+// across the supported formats, erroring if a name is defined more than
+// once.
 func getPatterns() ([]string, error) {
-	out := []string{}
-
 	patDir, err := getPatternDir()
 	if err != nil {
-		return out, fmt.Errorf("failed to determine pattern directory: %s", err)
-	}
-
-	files, err := filepath.Glob(patDir + "/*.json")
-	if err != nil {
-		return out, err
+		return nil, fmt.Errorf("failed to determine pattern directory: %s", err)
 	}
 
-	for _, f := range files {
-		f = f[len(patDir)+1 : len(f)-5]
-		out = append(out, f)
+	seenIn := map[string]string{}
+	out := []string{}
+	for _, ext := range patternExts {
+		files, err := filepath.Glob(patDir + "/*" + ext)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if strings.HasSuffix(f, fixtureSuffix) {
+				continue
+			}
+			name := strings.TrimSuffix(filepath.Base(f), ext)
+			if prev, ok := seenIn[name]; ok {
+				return nil, fmt.Errorf("duplicate pattern '%s' defined in both %s and %s", name, prev, f)
+			}
+			seenIn[name] = f
+			out = append(out, name)
+		}
 	}
 
+	sort.Strings(out)
 	return out, nil
 }
 
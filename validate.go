@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// validatePattern parses name's pattern file, checks that each regex
+// compiles under the pattern's configured engine, and returns any
+// non-fatal warnings (unknown fields, unusual engine names) alongside a
+// hard error if the file itself is broken.
+func validatePattern(name string) ([]string, error) {
+	patDir, err := getPatternDir()
+	if err != nil {
+		return nil, errors.New("unable to open user's pattern directory")
+	}
+
+	filename, err := findPatternFile(patDir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pat, err := decodePatternFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if pat.Pattern == "" && len(pat.Patterns) == 0 {
+		return nil, fmt.Errorf("pattern file '%s' contains no pattern(s)", filename)
+	}
+
+	var warnings []string
+
+	regexes := append([]string{}, pat.Patterns...)
+	if pat.Pattern != "" {
+		regexes = append(regexes, pat.Pattern)
+	}
+	for _, r := range regexes {
+		if err := compileCheck(pat.Engine, pat.Flags, r); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				warnings = append(warnings, fmt.Sprintf("could not validate regex %q: %s", r, err))
+				continue
+			}
+			return nil, fmt.Errorf("pattern file '%s': %s", filename, err)
+		}
+	}
+
+	unknown, err := unknownPatternFields(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range unknown {
+		warnings = append(warnings, fmt.Sprintf("pattern file '%s' has unknown field %q", filename, f))
+	}
+
+	if _, err := engineFor(pat.Engine); err != nil {
+		warnings = append(warnings, fmt.Sprintf("pattern file '%s' has unrecognized engine %q", filename, pat.Engine))
+	}
+
+	return warnings, nil
+}
+
+// regexDialectCommand returns the binary (and, for "git grep", its
+// subcommand) that actually evaluates regex under the named engine.
+func regexDialectCommand(engineName string) []string {
+	switch engineName {
+	case "":
+		return []string{"ugrep"}
+	case "ripgrep":
+		return []string{"rg"}
+	case "git grep":
+		return []string{"git", "grep"}
+	default:
+		return []string{engineName}
+	}
+}
+
+// compileCheck validates regex under engineName's actual dialect by
+// shelling out to a quiet, non-matching invocation against /dev/null and
+// inspecting its exit status, rather than Go's RE2-only regexp package.
+// This is needed because engines disagree on dialect (e.g. ripgrep's -P
+// accepts PCRE lookbehind, which regexp.Compile would reject even though
+// rg itself accepts it fine).
+//
+// grep and git grep also need the same extendedRegexDefault() applied by
+// their BuildArgs, or this would validate under POSIX basic regex while
+// -run actually searches under extended regex.
+func compileCheck(engineName string, flags []string, regex string) error {
+	cmdline := regexDialectCommand(engineName)
+	args := append([]string{}, cmdline[1:]...)
+	if engineName == "grep" || engineName == "git grep" {
+		args = append(args, extendedRegexDefault(flags)...)
+	}
+	args = append(args, flags...)
+	target := os.DevNull
+	if engineName == "git grep" {
+		// git grep rejects paths outside a repository, so search
+		// os.DevNull by its base name from its own directory, untracked.
+		args = append(args, "--no-index")
+		target = filepath.Base(os.DevNull)
+	}
+	args = append(args, "-e", regex, target)
+
+	cmd := exec.Command(cmdline[0], args...)
+	if engineName == "git grep" {
+		cmd.Dir = filepath.Dir(os.DevNull)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &exitErr):
+		// exit 1 means "no match", which is expected against /dev/null
+		// and isn't a syntax error; anything else is.
+		if exitErr.ExitCode() == 1 {
+			return nil
+		}
+		return fmt.Errorf("regex %q does not compile under %s: %s", regex, cmdline[0], strings.TrimSpace(stderr.String()))
+	default:
+		return fmt.Errorf("running %s: %w", cmdline[0], err)
+	}
+}